@@ -9,7 +9,7 @@ import (
 type builder interface {
 	enterGroupArray(key string, keys []string, position *Position) // TODO: keep just one of key or keys
 	enterGroup(key string, keys []string, position *Position)      // TODO: keep just one
-	enterAssign(key string, position *Position)
+	enterAssign(key string, keys []string, position *Position)
 	foundValue(value interface{}, position *Position)
 
 	enterArray()
@@ -17,6 +17,12 @@ type builder interface {
 
 	enterInlineTable()
 	exitInlineTable()
+
+	// abort resets any array or inline-table state left in progress when a
+	// ContinueOnError panic interrupts parsing before its matching
+	// exitArray/exitInlineTable runs, so the builder doesn't attribute
+	// values found after resync to an abandoned array or table.
+	abort()
 }
 
 type treeBuilder struct {
@@ -25,12 +31,20 @@ type treeBuilder struct {
 	seenTableKeys []string
 
 	assignKey      string   // TODO: probably don't need me, and at least needs a better name
+	assignKeys     []string // full dotted key path of the assignment, e.g. ["a", "b", "c"]
 	assignPosition Position // TODO: same
+	assignTree     *Tree    // table the current assignment's key is set on (differs from currentTree for dotted keys)
 	currentTree    *Tree    // points to the current tree being built
 
 	inArray   bool
 	array     []interface{}
 	arrayType reflect.Type
+
+	continueOnError bool // mirrors tomlParser.opts.continueOnError; see raiseError
+	spec            Spec // mirrors tomlParser.opts.spec; see foundValue, enterAssign
+
+	format         *FormatInfo
+	pendingLeading []string
 }
 
 func makeTreeBuilder() *treeBuilder {
@@ -40,11 +54,77 @@ func makeTreeBuilder() *treeBuilder {
 		tree:          tree,
 		currentTable:  make([]string, 0),
 		seenTableKeys: make([]string, 0),
+		currentTree:   tree,
+		format:        newFormatInfo(),
+	}
+}
+
+// currentPath returns the dotted path of the key currently being assigned,
+// for use as a FormatInfo lookup key.
+func (b *treeBuilder) currentPath() string {
+	return strings.Join(append(append([]string{}, b.currentTable...), b.assignKeys...), ".")
+}
+
+// currentFormatKey returns the FormatInfo key for the key currently being
+// assigned: its dotted path, disambiguated by the source line of the
+// table instance that holds it (b.assignTree), so that two [[array]]
+// items with the same field name don't collide in FormatInfo.entries.
+func (b *treeBuilder) currentFormatKey() string {
+	line := 0
+	if b.assignTree != nil {
+		line = b.assignTree.position.Line
+	}
+	return formatKey(b.currentPath(), line)
+}
+
+// recordSpan implements spanRecorder: it finalizes the formatEntry for the
+// key currently being assigned, attaching any leading comments collected
+// since the previous key.
+func (b *treeBuilder) recordSpan(span Span, raw string) {
+	// A scalar inside an array is an element, not the assigned key's own
+	// value; arrays get no entry of their own.
+	if b.inArray {
+		return
 	}
+	b.format.record(b.currentFormatKey(), &formatEntry{
+		span:    span,
+		raw:     raw,
+		leading: b.pendingLeading,
+	})
+	b.pendingLeading = nil
+}
+
+// recordComment implements spanRecorder. Leading comments are buffered
+// until the next recordSpan; a trailing comment is attached to the
+// formatEntry recordSpan already created for the current key.
+func (b *treeBuilder) recordComment(text string, leading bool) {
+	if leading {
+		b.pendingLeading = append(b.pendingLeading, text)
+		return
+	}
+	if e, ok := b.format.entries[b.currentFormatKey()]; ok {
+		e.trailing = text
+	}
+}
+
+// recordTableComment implements spanRecorder: it attaches a comment that
+// preceded a [table]/[[array]] header to the header itself, keyed by the
+// table's own path and source line, so it isn't mistaken for a leading
+// comment on the first key inside the table.
+func (b *treeBuilder) recordTableComment(text string) {
+	path := strings.Join(b.currentTable, ".")
+	b.format.recordTableComment(path, b.currentTree.position.Line, text)
 }
 
 func (b *treeBuilder) raiseError(position *Position, msg string, args ...interface{}) {
-	panic(position.String() + ": " + fmt.Sprintf(msg, args...))
+	pErr := &ParseError{Message: fmt.Sprintf(msg, args...)}
+	if position != nil {
+		pErr.Position = *position
+	}
+	if b.continueOnError {
+		panic(parseErrorSignal{pErr})
+	}
+	panic(pErr)
 }
 
 func (b *treeBuilder) enterGroupArray(key string, keys []string, position *Position) {
@@ -92,6 +172,19 @@ func (b *treeBuilder) enterGroupArray(key string, keys []string, position *Posit
 	}
 }
 
+// markSeenTable records path as a table that now exists, whether from an
+// explicit [table] header or a dotted-key assignment that implicitly
+// created it, so enterGroup's duplicate check also catches an explicit
+// header re-opening a table a dotted key already created.
+func (b *treeBuilder) markSeenTable(path string) {
+	for _, item := range b.seenTableKeys {
+		if item == path {
+			return
+		}
+	}
+	b.seenTableKeys = append(b.seenTableKeys, path)
+}
+
 func (b *treeBuilder) enterGroup(key string, keys []string, position *Position) {
 	for _, item := range b.seenTableKeys {
 		if item == key {
@@ -111,24 +204,68 @@ func (b *treeBuilder) enterGroup(key string, keys []string, position *Position)
 	b.currentTable = keys
 }
 
-func (b *treeBuilder) enterAssign(key string, position *Position) {
+// enterAssign handles both plain keys (keys has one element) and TOML 1.0
+// dotted keys (a.b.c = 1), which walk or create intermediate, implicit
+// subtrees under currentTree without changing currentTree itself --
+// unlike an explicit [table] header, a dotted key doesn't become the table
+// that subsequent keys are added to.
+func (b *treeBuilder) enterAssign(key string, keys []string, position *Position) {
 	b.assignPosition = *position
+	b.assignKeys = keys
 
-	if b.currentTree.values[key] != nil {
-		finalKey := append(b.currentTable, key)
-		b.raiseError(position, "The following key was defined twice: %s", strings.Join(finalKey, "."))
+	if b.spec == Spec04 && len(keys) > 1 {
+		b.raiseError(position, "dotted keys are not supported in TOML 0.4")
 	}
 
-	b.assignKey = key
+	target := b.currentTree
+	if len(keys) > 1 {
+		parent, err := target.createSubTree(keys[:len(keys)-1], *position)
+		if err != nil {
+			b.raiseError(position, "%s", err)
+		}
+		target = parent
+
+		// Every intermediate table the dotted key walked through exists
+		// only because of this assignment, not an explicit header; mark
+		// each so Marshal re-emits "a.b = 1" instead of inventing [a]. Key
+		// by the subtree's own source line too: a [[table array]] item
+		// reuses the same dotted path as every other item, so the path
+		// alone can't tell two occurrences apart. Also register the path
+		// in seenTableKeys, the same as an explicit header would, so a
+		// later "[a.b]" re-opening this implicitly-created table is
+		// rejected as a duplicate instead of silently accepted.
+		for i := 1; i < len(keys); i++ {
+			prefix := append(append([]string{}, b.currentTable...), keys[:i]...)
+			path := strings.Join(prefix, ".")
+			line := 0
+			if sub, ok := b.tree.GetPath(prefix).(*Tree); ok {
+				line = sub.position.Line
+			}
+			b.format.markDottedTable(formatKey(path, line))
+			b.markSeenTable(path)
+		}
+	}
+
+	finalKey := keys[len(keys)-1]
+	if target.values[finalKey] != nil {
+		finalPath := append(append([]string{}, b.currentTable...), keys...)
+		b.raiseError(position, "The following key was defined twice: %s", strings.Join(finalPath, "."))
+	}
+
+	b.assignTree = target
+	b.assignKey = finalKey
 }
 
 func (b *treeBuilder) foundValue(value interface{}, position *Position) {
 	if b.inArray {
-		if b.arrayType == nil {
-			b.arrayType = reflect.TypeOf(value)
-		}
-		if reflect.TypeOf(value) != b.arrayType {
-			b.raiseError(position, "mixed types in array")
+		// TOML 1.0 allows arrays to mix types; TOML 0.4 required them
+		// to be homogeneous.
+		if b.spec == Spec04 {
+			if b.arrayType == nil {
+				b.arrayType = reflect.TypeOf(value)
+			} else if reflect.TypeOf(value) != b.arrayType {
+				b.raiseError(position, "mixed types in array")
+			}
 		}
 		b.array = append(b.array, value)
 		return
@@ -151,19 +288,27 @@ func (b *treeBuilder) enterArray() {
 }
 
 func (b *treeBuilder) exitArray() {
-	// An array of Trees is actually an array of inline
-	// tables, which is a shorthand for a table array. If the
-	// array was not converted from []interface{} to []*Tree,
-	// the two notations would not be equivalent.
-	if b.arrayType == reflect.TypeOf(newTree()) {
+	// An array of Trees is actually an array of inline tables, which is
+	// a shorthand for a table array. If the array was not converted
+	// from []interface{} to []*Tree, the two notations would not be
+	// equivalent.
+	allTrees := len(b.array) > 0
+	for _, v := range b.array {
+		if _, ok := v.(*Tree); !ok {
+			allTrees = false
+			break
+		}
+	}
+
+	if allTrees {
 		tomlArray := make([]*Tree, len(b.array))
 		for i, v := range b.array {
 			tomlArray[i] = v.(*Tree)
 		}
 		b.assignTree.values[b.assignKey] = tomlArray
-		return
+	} else {
+		b.assignTree.values[b.assignKey] = &tomlValue{value: b.array, position: b.assignPosition}
 	}
-	b.assignTree.values[b.assignKey] = &tomlValue{value: b.array, position: b.assignPosition}
 	b.inArray = false
 }
 
@@ -174,3 +319,10 @@ func (b *treeBuilder) enterInlineTable() {
 func (b *treeBuilder) exitInlineTable() {
 
 }
+
+// abort implements builder. See the interface doc comment.
+func (b *treeBuilder) abort() {
+	b.inArray = false
+	b.array = nil
+	b.arrayType = nil
+}
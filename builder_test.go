@@ -0,0 +1,36 @@
+package toml
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseTopLevelKey guards against a nil-pointer panic in enterAssign:
+// makeTreeBuilder must point currentTree at the root tree before any
+// [table] header is seen, since a bare top-level key = value is valid
+// TOML and the most common document shape there is.
+func TestParseTopLevelKey(t *testing.T) {
+	tree, _, errs := parseToml(lexToml([]byte(`name = "bob"`)))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got := tree.Get("name"); got != "bob" {
+		t.Errorf("tree.Get(%q) = %v, want %q", "name", got, "bob")
+	}
+}
+
+// TestParseDottedKeyThenExplicitTableConflict guards against
+// treeBuilder.enterAssign walking a dotted key through an implicit table
+// without registering it in seenTableKeys: without that, an explicit
+// [a.b] header re-opening a table "a.b" already implicitly created would
+// be silently accepted as a fresh table instead of rejected the same way
+// enterGroup rejects any other re-declared table.
+func TestParseDottedKeyThenExplicitTableConflict(t *testing.T) {
+	_, _, errs := parseToml(lexToml([]byte("a.b.c = 1\n[a.b]\nx = 2\n")), ContinueOnError(0))
+	if len(errs) == 0 {
+		t.Fatal("expected an error re-opening the implicitly-created table a.b, got none")
+	}
+	if !strings.Contains(errs[0].Error(), "duplicated tables") {
+		t.Errorf("error = %q, want it to mention duplicated tables", errs[0].Error())
+	}
+}
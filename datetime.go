@@ -0,0 +1,83 @@
+package toml
+
+import (
+	"fmt"
+	"time"
+)
+
+// LocalDate is a TOML 1.0 "local date": a calendar date with no time
+// component or offset, e.g. 1979-05-27. It is a distinct type from
+// time.Time so that a round-trip through a *Tree can't silently attach a
+// timezone to a value that never had one.
+type LocalDate struct {
+	Year, Month, Day int
+}
+
+func (d LocalDate) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// LocalTime is a TOML 1.0 "local time": a wall-clock time with no date or
+// offset, e.g. 07:32:00.999999.
+type LocalTime struct {
+	Hour, Minute, Second, Nanosecond int
+}
+
+func (t LocalTime) String() string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if t.Nanosecond == 0 {
+		return s
+	}
+	return s + trimTrailingZeros(fmt.Sprintf(".%09d", t.Nanosecond))
+}
+
+// LocalDateTime is a TOML 1.0 "local date-time": a LocalDate and LocalTime
+// with no offset, e.g. 1979-05-27T07:32:00.
+type LocalDateTime struct {
+	Date LocalDate
+	Time LocalTime
+}
+
+func (dt LocalDateTime) String() string {
+	return dt.Date.String() + "T" + dt.Time.String()
+}
+
+func trimTrailingZeros(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if len(s) > 0 && s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func parseLocalDate(s string) (LocalDate, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return LocalDate{}, err
+	}
+	return LocalDate{Year: t.Year(), Month: int(t.Month()), Day: t.Day()}, nil
+}
+
+func parseLocalTime(s string) (LocalTime, error) {
+	t, err := time.Parse("15:04:05.999999999", s)
+	if err != nil {
+		return LocalTime{}, err
+	}
+	return LocalTime{Hour: t.Hour(), Minute: t.Minute(), Second: t.Second(), Nanosecond: t.Nanosecond()}, nil
+}
+
+func parseLocalDateTime(s string) (LocalDateTime, error) {
+	t, err := time.Parse("2006-01-02T15:04:05.999999999", s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02 15:04:05.999999999", s)
+	}
+	if err != nil {
+		return LocalDateTime{}, err
+	}
+	return LocalDateTime{
+		Date: LocalDate{Year: t.Year(), Month: int(t.Month()), Day: t.Day()},
+		Time: LocalTime{Hour: t.Hour(), Minute: t.Minute(), Second: t.Second(), Nanosecond: t.Nanosecond()},
+	}, nil
+}
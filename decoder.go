@@ -0,0 +1,319 @@
+package toml
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// TokenKind identifies the kind of event produced by a Decoder.
+type TokenKind int
+
+// The possible kinds of Token returned by Decoder.Token.
+const (
+	TableStart TokenKind = iota
+	TableEnd
+	ArrayTableStart
+	ArrayTableEnd
+	KeyValue
+	ArrayStart
+	ArrayEnd
+	InlineTableStart
+	InlineTableEnd
+)
+
+// Token is a single streaming parse event produced by a Decoder, analogous
+// to the tokens returned by encoding/json's Decoder.Token. Key and Keys
+// carry the key of the table or key-value pair the event applies to; Keys
+// holds the dotted key split into its parts. Value is only populated for
+// KeyValue events.
+type Token struct {
+	Kind     TokenKind
+	Key      string
+	Keys     []string
+	Value    interface{}
+	Position Position
+}
+
+// Decoder reads a stream of Tokens from a TOML document without
+// materializing the full *Tree, so a consumer can decode directly into an
+// application-specific data structure. NewDecoder reads all of r and the
+// whole document is lexed into tokens up front, so memory use still grows
+// with the size of the document.
+//
+// A Decoder must be read to completion (until Token returns io.EOF) or
+// closed with Close to release the goroutine driving the parse.
+type Decoder struct {
+	tokens chan Token
+	errc   chan error
+	done   chan struct{}
+	err    error
+	errors []ParseError
+	closed bool
+}
+
+// NewDecoder returns a Decoder that streams parse events read from r. opts
+// configures the underlying parser the same way it does for parseToml, so
+// ContinueOnError and WithSpec apply to streaming decodes too.
+func NewDecoder(r io.Reader, opts ...ParserOption) (*Decoder, error) {
+	input, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var popts parserOptions
+	for _, opt := range opts {
+		opt(&popts)
+	}
+
+	d := &Decoder{
+		tokens: make(chan Token),
+		errc:   make(chan error, 1),
+		done:   make(chan struct{}),
+	}
+
+	go d.run(input, popts)
+
+	return d, nil
+}
+
+func (d *Decoder) run(input []byte, popts parserOptions) {
+	defer close(d.tokens)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(error); ok {
+				d.errc <- err
+			} else {
+				d.errc <- fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	eb := &eventBuilder{tokens: d.tokens, done: d.done, spec: popts.spec, continueOnError: popts.continueOnError}
+	parser := &tomlParser{
+		flowIdx: 0,
+		flow:    lexToml(input),
+		builder: eb,
+		opts:    popts,
+	}
+	parser.run()
+	eb.closeTables()
+	d.errors = parser.errors
+	d.errc <- nil
+}
+
+// Errors returns the ParseErrors collected under ContinueOnError. It is
+// only meaningful once Token has returned io.EOF; with ContinueOnError
+// unset, any error instead aborts the decode and is returned from Token.
+func (d *Decoder) Errors() []ParseError {
+	return d.errors
+}
+
+// Token returns the next event in the document, or io.EOF once the
+// document has been fully consumed.
+func (d *Decoder) Token() (Token, error) {
+	if d.err != nil {
+		return Token{}, d.err
+	}
+
+	tok, ok := <-d.tokens
+	if !ok {
+		d.err = <-d.errc
+		if d.err == nil {
+			d.err = io.EOF
+		}
+		return Token{}, d.err
+	}
+
+	return tok, nil
+}
+
+// Close stops the Decoder, releasing the goroutine driving the parse. It
+// is safe to call Close after the Decoder has already reached io.EOF.
+func (d *Decoder) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	close(d.done)
+	for range d.tokens {
+	}
+	return nil
+}
+
+// eventBuilder implements builder by translating each parser callback into
+// a Token sent on a channel, so a Decoder can stream events without ever
+// constructing a *Tree. It synthesizes the TableEnd/ArrayTableEnd events
+// that the underlying builder interface has no explicit hook for, by
+// tracking the currently open table.
+type eventBuilder struct {
+	tokens chan<- Token
+	done   <-chan struct{}
+
+	continueOnError bool // mirrors tomlParser.opts.continueOnError; see raiseError
+	spec            Spec // mirrors tomlParser.opts.spec; see enterAssign, foundValue
+
+	openTable   []string
+	openIsArray bool
+	haveOpen    bool
+
+	pendingKey  string
+	pendingKeys []string
+	pendingPos  Position
+
+	// assignedScalar and assignedTable track, within the current table
+	// scope, which dotted key paths already hold a value and which were
+	// walked through as an implicit table by a longer dotted key. See
+	// enterAssign.
+	assignedScalar map[string]bool
+	assignedTable  map[string]bool
+
+	inArray   bool
+	arrayElem []interface{}
+	arrayType reflect.Type
+}
+
+func (b *eventBuilder) raiseError(position *Position, msg string, args ...interface{}) {
+	pErr := &ParseError{Message: fmt.Sprintf(msg, args...)}
+	if position != nil {
+		pErr.Position = *position
+	}
+	if b.continueOnError {
+		panic(parseErrorSignal{pErr})
+	}
+	panic(pErr)
+}
+
+func (b *eventBuilder) emit(tok Token) {
+	select {
+	case b.tokens <- tok:
+	case <-b.done:
+	}
+}
+
+func (b *eventBuilder) closeTables() {
+	if !b.haveOpen {
+		return
+	}
+	kind := TableEnd
+	if b.openIsArray {
+		kind = ArrayTableEnd
+	}
+	b.emit(Token{Kind: kind, Key: last(b.openTable), Keys: b.openTable})
+	b.haveOpen = false
+}
+
+func (b *eventBuilder) enterGroupArray(key string, keys []string, position *Position) {
+	b.closeTables()
+	b.emit(Token{Kind: ArrayTableStart, Key: key, Keys: keys, Position: *position})
+	b.openTable, b.openIsArray, b.haveOpen = keys, true, true
+	b.assignedScalar = nil
+	b.assignedTable = nil
+}
+
+func (b *eventBuilder) enterGroup(key string, keys []string, position *Position) {
+	b.closeTables()
+	b.emit(Token{Kind: TableStart, Key: key, Keys: keys, Position: *position})
+	b.openTable, b.openIsArray, b.haveOpen = keys, false, true
+	b.assignedScalar = nil
+	b.assignedTable = nil
+}
+
+// enterAssign mirrors treeBuilder.enterAssign's duplicate-key check: since
+// eventBuilder never materializes a *Tree to check against, it tracks the
+// dotted keys assigned, and the ones walked through as an implicit table,
+// in the current table scope itself, resetting whenever
+// enterGroup/enterGroupArray open a new one. A dotted key conflicts with
+// an earlier assignment if it repeats one exactly, if it was already
+// walked through as an implicit table (assigning over a table), or if a
+// shorter prefix of it already holds a scalar value (extending a scalar
+// into a table) -- the same three cases treeBuilder's createSubTree and
+// "defined twice" check reject.
+func (b *eventBuilder) enterAssign(key string, keys []string, position *Position) {
+	if b.spec == Spec04 && len(keys) > 1 {
+		b.raiseError(position, "dotted keys are not supported in TOML 0.4")
+	}
+
+	dotted := strings.Join(keys, ".")
+	if b.assignedScalar[dotted] || b.assignedTable[dotted] {
+		finalPath := append(append([]string{}, b.openTable...), keys...)
+		b.raiseError(position, "The following key was defined twice: %s", strings.Join(finalPath, "."))
+	}
+	for i := 1; i < len(keys); i++ {
+		prefix := strings.Join(keys[:i], ".")
+		if b.assignedScalar[prefix] {
+			fullPrefix := append(append([]string{}, b.openTable...), keys[:i]...)
+			b.raiseError(position, "The following key was defined twice: %s", strings.Join(fullPrefix, "."))
+		}
+	}
+
+	if b.assignedScalar == nil {
+		b.assignedScalar = make(map[string]bool)
+	}
+	b.assignedScalar[dotted] = true
+	for i := 1; i < len(keys); i++ {
+		if b.assignedTable == nil {
+			b.assignedTable = make(map[string]bool)
+		}
+		b.assignedTable[strings.Join(keys[:i], ".")] = true
+	}
+
+	b.pendingKey = key
+	b.pendingKeys = keys
+	b.pendingPos = *position
+}
+
+func (b *eventBuilder) foundValue(value interface{}, position *Position) {
+	if b.inArray {
+		// TOML 1.0 allows arrays to mix types; TOML 0.4 required them
+		// to be homogeneous.
+		if b.spec == Spec04 {
+			if b.arrayType == nil {
+				b.arrayType = reflect.TypeOf(value)
+			} else if reflect.TypeOf(value) != b.arrayType {
+				b.raiseError(position, "mixed types in array")
+			}
+		}
+		b.arrayElem = append(b.arrayElem, value)
+		return
+	}
+	b.emit(Token{Kind: KeyValue, Key: b.pendingKey, Keys: b.pendingKeys, Value: value, Position: b.pendingPos})
+}
+
+func (b *eventBuilder) enterArray() {
+	b.inArray = true
+	b.arrayElem = nil
+	b.arrayType = nil
+	b.emit(Token{Kind: ArrayStart, Key: b.pendingKey, Position: b.pendingPos})
+}
+
+func (b *eventBuilder) exitArray() {
+	b.inArray = false
+	b.emit(Token{Kind: KeyValue, Key: b.pendingKey, Keys: b.pendingKeys, Value: b.arrayElem, Position: b.pendingPos})
+	b.emit(Token{Kind: ArrayEnd, Key: b.pendingKey, Position: b.pendingPos})
+}
+
+func (b *eventBuilder) enterInlineTable() {
+	b.emit(Token{Kind: InlineTableStart, Key: b.pendingKey, Position: b.pendingPos})
+}
+
+func (b *eventBuilder) exitInlineTable() {
+	b.emit(Token{Kind: InlineTableEnd, Key: b.pendingKey, Position: b.pendingPos})
+}
+
+// abort implements builder. See the interface doc comment.
+func (b *eventBuilder) abort() {
+	b.inArray = false
+	b.arrayElem = nil
+	b.arrayType = nil
+}
+
+func last(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[len(keys)-1]
+}
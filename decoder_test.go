@@ -0,0 +1,96 @@
+package toml
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNewDecoderAppliesContinueOnError(t *testing.T) {
+	// One document that assigns "a" twice: without ContinueOnError wired
+	// through, NewDecoder aborts on the first error instead of
+	// resynchronizing and decoding the rest.
+	src := "a = 1\na = 2\nb = 3\n"
+
+	dec, err := NewDecoder(strings.NewReader(src), ContinueOnError(0))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	var keys []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		if tok.Kind == KeyValue {
+			keys = append(keys, tok.Key)
+		}
+	}
+
+	if len(dec.Errors()) == 0 {
+		t.Error("Errors() is empty, want the duplicate-key error collected")
+	}
+
+	found := false
+	for _, k := range keys {
+		if k == "b" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("keys = %v, want decoding to resynchronize and still reach %q", keys, "b")
+	}
+}
+
+// TestNewDecoderDetectsDottedKeyPrefixConflict guards against
+// eventBuilder.enterAssign only catching an exact repeat of a dotted key:
+// "a.b.c" extends "a.b" into a table after "a.b" already held a scalar,
+// which must be rejected the same way treeBuilder rejects it via
+// createSubTree, even though eventBuilder never materializes a *Tree to
+// check against.
+func TestNewDecoderDetectsDottedKeyPrefixConflict(t *testing.T) {
+	src := "a.b = 1\na.b.c = 2\n"
+
+	dec, err := NewDecoder(strings.NewReader(src), ContinueOnError(0))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+	}
+
+	if len(dec.Errors()) == 0 {
+		t.Error("Errors() is empty, want a conflict error for extending a.b into a table")
+	}
+}
+
+func TestNewDecoderAppliesSpec(t *testing.T) {
+	dec, err := NewDecoder(strings.NewReader(`a = [1, "two"]`), WithSpec(Spec04))
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+
+	var gotErr error
+	for {
+		_, err := dec.Token()
+		if err != nil {
+			gotErr = err
+			break
+		}
+	}
+
+	if gotErr == io.EOF || gotErr == nil {
+		t.Error("mixed-type array decoded cleanly under Spec04, want a ParseError")
+	}
+}
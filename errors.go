@@ -0,0 +1,91 @@
+package toml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseError is returned (or, under ContinueOnError, accumulated) when the
+// parser encounters malformed TOML. It carries enough context for editor
+// integrations and linters to render a precise, actionable diagnostic.
+type ParseError struct {
+	Position Position // where the error occurred
+	File     string   // source file name, if known
+	Message  string   // human-readable description
+	Snippet  string   // the offending source line, if available
+	Cause    error    // the underlying error, if any
+}
+
+func (e *ParseError) Error() string {
+	where := fmt.Sprintf("%d:%d", e.Position.Line, e.Position.Col)
+	if e.File != "" {
+		where = e.File + " " + where
+	}
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %s", where, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", where, e.Message)
+}
+
+// Unwrap allows ParseError to participate in errors.Is / errors.As chains.
+func (e *ParseError) Unwrap() error {
+	return e.Cause
+}
+
+// parseErrorSignal wraps a *ParseError so that raiseError's panic can be
+// told apart, by p.step's recover, from an unrelated panic originating
+// deeper in the call stack.
+type parseErrorSignal struct {
+	err *ParseError
+}
+
+// parserOptions configures a tomlParser. Parser options are applied by
+// parseToml before the parser runs.
+type parserOptions struct {
+	file            string
+	source          []string
+	continueOnError bool
+	maxErrors       int
+	spec            Spec
+}
+
+func (o *parserOptions) snippet(pos Position) string {
+	idx := pos.Line - 1
+	if idx < 0 || idx >= len(o.source) {
+		return ""
+	}
+	return o.source[idx]
+}
+
+// ParserOption configures how a TOML document is parsed.
+type ParserOption func(*parserOptions)
+
+// WithFilename attaches a file name to the ParseErrors the parser raises
+// for malformed syntax, so they are reported as "config.toml 12:4: ..."
+// rather than just "12:4: ...". Errors raised by the builder (a key or
+// table defined twice, a mixed-type array under Spec04) carry no File.
+func WithFilename(name string) ParserOption {
+	return func(o *parserOptions) {
+		o.file = name
+	}
+}
+
+// WithSource attaches the original document text so that ParseError.Snippet
+// can be populated with the offending line.
+func WithSource(source string) ParserOption {
+	return func(o *parserOptions) {
+		o.source = strings.Split(source, "\n")
+	}
+}
+
+// ContinueOnError makes the parser collect up to maxErrors ParseErrors
+// instead of aborting on the first one. The parser resynchronizes at the
+// next top-level table header or key, so editor integrations and linters
+// can report every problem in a document in one pass. maxErrors <= 0 means
+// unlimited.
+func ContinueOnError(maxErrors int) ParserOption {
+	return func(o *parserOptions) {
+		o.continueOnError = true
+		o.maxErrors = maxErrors
+	}
+}
@@ -0,0 +1,51 @@
+package toml
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrorError(t *testing.T) {
+	err := &ParseError{
+		Position: Position{Line: 3, Col: 5},
+		Message:  "unexpected token",
+	}
+	want := "3:5: unexpected token"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	err.File = "config.toml"
+	want = "config.toml 3:5: unexpected token"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() with File = %q, want %q", got, want)
+	}
+}
+
+func TestParseErrorUnwrap(t *testing.T) {
+	cause := errors.New("boom")
+	err := &ParseError{Message: "failed", Cause: cause}
+
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false, want true")
+	}
+	if got := err.Error(); got == "" {
+		t.Error("Error() with a Cause returned an empty string")
+	}
+}
+
+// TestContinueOnErrorResetsArrayState guards against resync picking up
+// after a malformed array mid-parseArray without the builder's in-progress
+// array state being reset: foundValue for the next, unrelated key would
+// otherwise silently append into the abandoned array instead of the key
+// ever reaching the tree.
+func TestContinueOnErrorResetsArrayState(t *testing.T) {
+	tree, _, errs := parseToml(lexToml([]byte("a = [1, 2\nb = 3\n")), ContinueOnError(0))
+	if len(errs) != 1 {
+		t.Fatalf("errs = %v, want exactly one error for the malformed array", errs)
+	}
+
+	if got, want := tree.Get("b"), int64(3); got != want {
+		t.Errorf(`tree.Get("b") = %#v, want %#v (resync must not leave "b" swallowed into the abandoned array)`, got, want)
+	}
+}
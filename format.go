@@ -0,0 +1,315 @@
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Span is the half-open source range [Start, End) a parsed value occupied
+// in the original document.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// formatEntry records everything needed to reproduce a key's original
+// source formatting: its span, the raw token text (so re-emitting an
+// unmodified value doesn't depend on the marshaler's own number/string
+// formatting), and any comments attached to it during parsing.
+type formatEntry struct {
+	span     Span
+	raw      string
+	leading  []string
+	trailing string
+}
+
+// FormatInfo carries the source spans, raw token text and comments
+// collected while parsing a document, keyed by dotted key path (e.g.
+// "servers.alpha.ip") disambiguated by source line via formatKey, since a
+// [[table array]]'s items all share the same dotted paths. Passing it to
+// WriteTo/Marshal alongside the *Tree it was collected from lets those
+// reproduce the original formatting, comment placement and key ordering
+// for keys that weren't modified, which is what turns this into a
+// config-editing library rather than a destroy-and-rebuild serializer: a
+// tool can parse a user's file, change one key, and get back their file
+// with everything else untouched.
+type FormatInfo struct {
+	entries       map[string]*formatEntry // formatKey(path, line) -> entry
+	order         []string                // formatKey(path, line), in the order they were recorded
+	dottedTable   map[string]bool         // formatKey(path, line) -> true
+	tableComments map[string][]string     // formatKey(path, line) -> leading comments before a [table]/[[array]] header
+}
+
+func newFormatInfo() *FormatInfo {
+	return &FormatInfo{entries: make(map[string]*formatEntry)}
+}
+
+// markDottedTable records that the table occurrence identified by key (a
+// formatKey-disambiguated path) exists only because a dotted-key
+// assignment (a.b.c = 1) walked through it, not because of an explicit
+// [a.b] header, so writeTree re-emits "a.b = 1" inline instead of
+// inventing a header for an implicit table.
+func (f *FormatInfo) markDottedTable(key string) {
+	if f.dottedTable == nil {
+		f.dottedTable = make(map[string]bool)
+	}
+	f.dottedTable[key] = true
+}
+
+// isDottedTable reports whether key was marked by markDottedTable.
+func (f *FormatInfo) isDottedTable(key string) bool {
+	return f.dottedTable[key]
+}
+
+// formatKey identifies one occurrence of a dotted key path: the path
+// itself plus the source line of the table instance it belongs to. A
+// dotted path alone isn't unique -- every item of a [[table array]] shares
+// the same field names, so two "[[servers]]" entries both have an "ip"
+// child at path "servers.ip" -- and recording both under that bare path
+// would make the second overwrite the first in FormatInfo.entries.
+func formatKey(path string, line int) string {
+	return path + "@" + strconv.Itoa(line)
+}
+
+// formatPath strips the "@line" disambiguator a formatKey added, back to
+// the plain dotted key path.
+func formatPath(key string) string {
+	if i := strings.LastIndex(key, "@"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// recordTableComment records a leading comment line for the table/array
+// header at path, occurring at the given source line.
+func (f *FormatInfo) recordTableComment(path string, line int, text string) {
+	if f.tableComments == nil {
+		f.tableComments = make(map[string][]string)
+	}
+	key := formatKey(path, line)
+	f.tableComments[key] = append(f.tableComments[key], text)
+}
+
+// tableComment returns the leading comments recorded for the table/array
+// header at path and line, if any.
+func (f *FormatInfo) tableComment(path string, line int) []string {
+	return f.tableComments[formatKey(path, line)]
+}
+
+// record stores e under key, a formatKey-disambiguated path.
+func (f *FormatInfo) record(key string, e *formatEntry) {
+	if _, ok := f.entries[key]; !ok {
+		f.order = append(f.order, key)
+	}
+	f.entries[key] = e
+}
+
+// Span reports the source span recorded for the dotted key path, and
+// whether one was recorded at all (it won't be for a key added to the
+// tree programmatically after parsing). If path was assigned by more than
+// one table-array item, which occurrence is returned is unspecified.
+func (f *FormatInfo) Span(path string) (Span, bool) {
+	for key, e := range f.entries {
+		if formatPath(key) == path {
+			return e.span, true
+		}
+	}
+	return Span{}, false
+}
+
+// spanRecorder is implemented by builders that want source spans, raw
+// token text and comments attached to each value as it is parsed.
+// treeBuilder implements it to support round-trip editing; the streaming
+// eventBuilder does not, since it never materializes a *Tree to attach
+// them to.
+type spanRecorder interface {
+	recordSpan(span Span, raw string)
+	recordComment(text string, leading bool)
+	recordTableComment(text string)
+}
+
+// spanEnd approximates the end position of tok from its raw text,
+// advancing past embedded newlines for multi-line strings.
+func spanEnd(tok *token) Position {
+	pos := tok.Position
+	lines := strings.Split(tok.val, "\n")
+	if len(lines) == 1 {
+		pos.Col += len(tok.val)
+		return pos
+	}
+	pos.Line += len(lines) - 1
+	pos.Col = len(lines[len(lines)-1]) + 1
+	return pos
+}
+
+// WriteTo writes tree to w as TOML. When format is non-nil, any key it has
+// a recorded entry for is emitted with its original raw text, comments and
+// relative ordering; keys with no recorded entry (added or changed since
+// parsing) are appended using default formatting. format may be nil, in
+// which case WriteTo behaves as a plain serializer.
+func WriteTo(tree *Tree, format *FormatInfo, w io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+	writeTree(buf, tree, nil, format)
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// Marshal renders tree to TOML text. See WriteTo for how format affects
+// the output.
+func Marshal(tree *Tree, format *FormatInfo) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if _, err := WriteTo(tree, format, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTree(buf *bytes.Buffer, tree *Tree, path []string, format *FormatInfo) {
+	for _, key := range orderedKeys(tree, path, format) {
+		keyPath := append(append([]string{}, path...), key)
+		dotted := strings.Join(keyPath, ".")
+
+		switch v := tree.values[key].(type) {
+		case *Tree:
+			if format != nil && format.isDottedTable(formatKey(dotted, v.position.Line)) {
+				writeDottedChildren(buf, v, keyPath, key, format)
+				break
+			}
+			writeTableHeader(buf, format, dotted, "[%s]", v.position.Line)
+			writeTree(buf, v, keyPath, format)
+		case []*Tree:
+			for _, item := range v {
+				writeTableHeader(buf, format, dotted, "[[%s]]", item.position.Line)
+				writeTree(buf, item, keyPath, format)
+			}
+		case *tomlValue:
+			writeLeaf(buf, key, v.value, dotted, tree.position.Line, format)
+		}
+	}
+}
+
+// writeTableHeader writes a blank line, any comments recorded for the
+// table/array header at dotted+line, then the header itself built from
+// headerFmt (one of "[%s]" or "[[%s]]").
+func writeTableHeader(buf *bytes.Buffer, format *FormatInfo, dotted, headerFmt string, line int) {
+	buf.WriteByte('\n')
+	if format != nil {
+		for _, c := range format.tableComment(dotted, line) {
+			fmt.Fprintf(buf, "#%s\n", c)
+		}
+	}
+	fmt.Fprintf(buf, headerFmt+"\n", dotted)
+}
+
+// writeDottedChildren emits the leaves of an implicit table created by a
+// dotted-key assignment inline as "prefix.sub = value" rather than opening
+// a [prefix] header, recursing through any further dotted levels under it.
+func writeDottedChildren(buf *bytes.Buffer, tree *Tree, path []string, prefix string, format *FormatInfo) {
+	for _, key := range orderedKeys(tree, path, format) {
+		keyPath := append(append([]string{}, path...), key)
+		dotted := strings.Join(keyPath, ".")
+		relKey := prefix + "." + key
+
+		switch v := tree.values[key].(type) {
+		case *Tree:
+			if format.isDottedTable(formatKey(dotted, v.position.Line)) {
+				writeDottedChildren(buf, v, keyPath, relKey, format)
+				continue
+			}
+			writeTableHeader(buf, format, dotted, "[%s]", v.position.Line)
+			writeTree(buf, v, keyPath, format)
+		case []*Tree:
+			for _, item := range v {
+				writeTableHeader(buf, format, dotted, "[[%s]]", item.position.Line)
+				writeTree(buf, item, keyPath, format)
+			}
+		case *tomlValue:
+			writeLeaf(buf, relKey, v.value, dotted, tree.position.Line, format)
+		}
+	}
+}
+
+func writeLeaf(buf *bytes.Buffer, key string, value interface{}, dotted string, line int, format *FormatInfo) {
+	var entry *formatEntry
+	if format != nil {
+		entry = format.entries[formatKey(dotted, line)]
+	}
+
+	if entry != nil {
+		for _, c := range entry.leading {
+			fmt.Fprintf(buf, "#%s\n", c)
+		}
+	}
+
+	raw := ""
+	if entry != nil {
+		raw = entry.raw
+	}
+	if raw == "" {
+		raw = formatValue(value)
+	}
+
+	fmt.Fprintf(buf, "%s = %s", key, raw)
+	if entry != nil && entry.trailing != "" {
+		fmt.Fprintf(buf, " #%s", entry.trailing)
+	}
+	buf.WriteByte('\n')
+}
+
+// formatValue is the fallback serialization for a value with no recorded
+// raw text, i.e. one that was added or changed after parsing.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// orderedKeys returns the direct children of tree (rooted at path) in the
+// order format recorded them, followed by any keys format doesn't know
+// about (new or programmatically-added keys), sorted for determinism.
+func orderedKeys(tree *Tree, path []string, format *FormatInfo) []string {
+	seen := make(map[string]bool, len(tree.values))
+	ordered := make([]string, 0, len(tree.values))
+
+	if format != nil {
+		prefix := ""
+		if len(path) > 0 {
+			prefix = strings.Join(path, ".") + "."
+		}
+		for _, key := range format.order {
+			dotted := formatPath(key)
+			if !strings.HasPrefix(dotted, prefix) {
+				continue
+			}
+			rest := dotted[len(prefix):]
+			if rest == "" || strings.Contains(rest, ".") {
+				continue // not a direct child of this table
+			}
+			if seen[rest] {
+				continue
+			}
+			if _, ok := tree.values[rest]; !ok {
+				continue
+			}
+			seen[rest] = true
+			ordered = append(ordered, rest)
+		}
+	}
+
+	var rest []string
+	for key := range tree.values {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(ordered, rest...)
+}
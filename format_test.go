@@ -0,0 +1,81 @@
+package toml
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMarshalRoundTripsString guards against recordSpan capturing the
+// lexer's decoded string value (quotes stripped, escapes resolved) as the
+// raw text to replay on Marshal: an unmodified string key must come back
+// out quoted, not as a bare, syntactically invalid word.
+func TestMarshalRoundTripsString(t *testing.T) {
+	src := `name = "bob"`
+	tree, format, errs := parseToml(lexToml([]byte(src)))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	out, err := Marshal(tree, format)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	_, _, reparseErrs := parseToml(lexToml(out))
+	if len(reparseErrs) != 0 {
+		t.Fatalf("round-tripped output %q does not re-parse: %v", out, reparseErrs)
+	}
+}
+
+// TestMarshalAttachesCommentToTableHeader guards against a comment
+// preceding a [table] header being carried forward and attached to the
+// first key inside the table instead of the header itself.
+func TestMarshalAttachesCommentToTableHeader(t *testing.T) {
+	src := "# server config\n[server]\nhost = \"x\"\n"
+	tree, format, errs := parseToml(lexToml([]byte(src)))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	out, err := Marshal(tree, format)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimLeft(string(out), "\n"), "\n")
+	if len(lines) < 2 || lines[0] != "#server config" || lines[1] != "[server]" {
+		t.Fatalf("Marshal output = %q, want the comment directly before the [server] header", out)
+	}
+}
+
+// TestMarshalArrayTableItemsDontCollide guards against FormatInfo keying a
+// [[table array]] child by dotted path alone: every item has an "ip" key
+// at the same path ("servers.ip"), so without disambiguating entries by
+// the item's own source line, the second item's raw text silently
+// overwrote the first's in FormatInfo.entries and Marshal re-emitted the
+// second server's ip for both.
+func TestMarshalArrayTableItemsDontCollide(t *testing.T) {
+	src := "[[servers]]\nip = \"10.0.0.1\"\n\n[[servers]]\nip = \"10.0.0.2\"\n"
+	tree, format, errs := parseToml(lexToml([]byte(src)))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	out, err := Marshal(tree, format)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got, _, reparseErrs := parseToml(lexToml(out))
+	if len(reparseErrs) != 0 {
+		t.Fatalf("round-tripped output %q does not re-parse: %v", out, reparseErrs)
+	}
+
+	servers, ok := got.GetPath([]string{"servers"}).([]*Tree)
+	if !ok || len(servers) != 2 {
+		t.Fatalf("round-tripped servers = %v, want 2 table array entries", servers)
+	}
+	if servers[0].Get("ip") != "10.0.0.1" || servers[1].Get("ip") != "10.0.0.2" {
+		t.Errorf("round-tripped ips = [%v, %v], want [10.0.0.1, 10.0.0.2]", servers[0].Get("ip"), servers[1].Get("ip"))
+	}
+}
@@ -17,18 +17,90 @@ type tomlParser struct {
 	flowIdx int
 	flow    []token
 	builder builder
+	opts    parserOptions
+	errors  []ParseError
+
+	pendingComments []string // leading comment lines seen since the last key
+}
+
+// takePendingComments returns and clears the leading comment lines
+// accumulated since the last key.
+func (p *tomlParser) takePendingComments() []string {
+	comments := p.pendingComments
+	p.pendingComments = nil
+	return comments
 }
 
 type tomlParserStateFn func() tomlParserStateFn
 
-// Formats and panics an error message based on a token
+// raiseError builds a *ParseError for tok and panics with it. Under
+// ContinueOnError the panic is wrapped in parseErrorSignal so that run's
+// recover loop can collect it and resynchronize instead of aborting.
 func (p *tomlParser) raiseError(tok *token, msg string, args ...interface{}) {
-	panic(tok.Position.String() + ": " + fmt.Sprintf(msg, args...))
+	pErr := &ParseError{Message: fmt.Sprintf(msg, args...), File: p.opts.file}
+	if tok != nil {
+		pErr.Position = tok.Position
+		pErr.Snippet = p.opts.snippet(tok.Position)
+	}
+	if p.opts.continueOnError {
+		panic(parseErrorSignal{pErr})
+	}
+	panic(pErr)
 }
 
 func (p *tomlParser) run() {
 	for state := p.parseStart; state != nil; {
-		state = state()
+		state = p.step(state)
+	}
+}
+
+// step runs a single state function, recovering from a parseErrorSignal
+// panic raised under ContinueOnError so parsing can resynchronize at the
+// next top-level table or key instead of aborting the whole document.
+func (p *tomlParser) step(state tomlParserStateFn) (next tomlParserStateFn) {
+	if !p.opts.continueOnError {
+		return state()
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		sig, ok := r.(parseErrorSignal)
+		if !ok {
+			panic(r)
+		}
+		p.errors = append(p.errors, *sig.err)
+		if p.opts.maxErrors > 0 && len(p.errors) >= p.opts.maxErrors {
+			next = nil
+			return
+		}
+		// The panic unwound out of parseArray/parseInlineTable before its
+		// matching exitArray/exitInlineTable ran, so the builder would
+		// otherwise still think it's mid-array/inline-table and silently
+		// route whatever resync finds next into the abandoned one.
+		p.builder.abort()
+		next = p.resync
+	}()
+
+	return state()
+}
+
+// resync skips tokens until the next top-level table header or key, so
+// that a ContinueOnError parse can keep collecting errors after a
+// malformed statement instead of aborting.
+func (p *tomlParser) resync() tomlParserStateFn {
+	for {
+		tok := p.peek()
+		if tok == nil || tok.typ == tokenEOF {
+			return nil
+		}
+		switch tok.typ {
+		case tokenLeftBracket, tokenDoubleLeftBracket, tokenKey:
+			return p.parseStart
+		}
+		p.getToken()
 	}
 }
 
@@ -67,6 +139,10 @@ func (p *tomlParser) parseStart() tomlParserStateFn {
 	}
 
 	switch tok.typ {
+	case tokenComment:
+		p.getToken()
+		p.pendingComments = append(p.pendingComments, tok.val)
+		return p.parseStart
 	case tokenDoubleLeftBracket:
 		return p.parseGroupArray
 	case tokenLeftBracket:
@@ -93,8 +169,16 @@ func (p *tomlParser) parseGroupArray() tomlParserStateFn {
 		p.raiseError(key, "invalid table array key: %s", err)
 	}
 
+	leading := p.takePendingComments()
+
 	p.builder.enterGroupArray(key.val, keys, &startToken.Position)
 
+	if sr, ok := p.builder.(spanRecorder); ok {
+		for _, c := range leading {
+			sr.recordTableComment(c)
+		}
+	}
+
 	// move to next parser state
 	p.assume(tokenDoubleRightBracket)
 	return p.parseStart
@@ -112,8 +196,16 @@ func (p *tomlParser) parseGroup() tomlParserStateFn {
 		p.raiseError(key, "invalid table array key: %s", err)
 	}
 
+	leading := p.takePendingComments()
+
 	p.builder.enterGroup(key.val, keys, &startToken.Position)
 
+	if sr, ok := p.builder.(spanRecorder); ok {
+		for _, c := range leading {
+			sr.recordTableComment(c)
+		}
+	}
+
 	p.assume(tokenRightBracket)
 	return p.parseStart
 }
@@ -122,10 +214,30 @@ func (p *tomlParser) parseAssign() tomlParserStateFn {
 	key := p.getToken()
 	p.assume(tokenEqual)
 
-	p.builder.enterAssign(key.val, &key.Position)
+	keys, err := parseKey(key.val)
+	if err != nil {
+		p.raiseError(key, "invalid key: %s", err)
+	}
+
+	leading := p.takePendingComments()
+
+	p.builder.enterAssign(key.val, keys, &key.Position)
+
+	if sr, ok := p.builder.(spanRecorder); ok {
+		for _, c := range leading {
+			sr.recordComment(c, true)
+		}
+	}
 
 	p.parseRvalue()
 
+	if sr, ok := p.builder.(spanRecorder); ok {
+		if follow := p.peek(); follow != nil && follow.typ == tokenComment && follow.Position.Line == key.Position.Line {
+			p.getToken()
+			sr.recordComment(follow.val, false)
+		}
+	}
+
 	// p.exitAssign() TODO: maybe?
 
 	return p.parseStart
@@ -153,13 +265,78 @@ func cleanupNumberToken(value string) string {
 	return cleanedVal
 }
 
+// classifyDateToken narrows a tokenDate value to the TOML 1.0 kind it
+// actually represents: an offset date-time ("1979-05-27T07:32:00Z"), a
+// local date-time with no offset ("1979-05-27T07:32:00"), a bare local
+// date ("1979-05-27") or a bare local time ("07:32:00").
+func classifyDateToken(val string) tokenType {
+	hasDate := len(val) >= 10 && val[4] == '-' && val[7] == '-'
+	hasTime := strings.Contains(val, ":")
+
+	switch {
+	case hasDate && !hasTime:
+		return tokenLocalDate
+	case hasTime && !hasDate:
+		return tokenLocalTime
+	case hasDate && hasTime:
+		if dateTokenHasOffset(val) {
+			return tokenDate
+		}
+		return tokenLocalDateTime
+	default:
+		return tokenDate
+	}
+}
+
+// dateTokenHasOffset reports whether val's time portion carries a "Z" or
+// "+hh:mm"/"-hh:mm" offset, which is what distinguishes an offset
+// date-time from a local date-time. The date portion's own "-"s are
+// skipped by only inspecting the part after the 'T'/' ' separator.
+func dateTokenHasOffset(val string) bool {
+	sep := strings.IndexAny(val, "Tt ")
+	if sep < 0 {
+		return false
+	}
+	rest := val[sep+1:]
+	return strings.ContainsAny(rest, "Zz+-")
+}
+
 func (p *tomlParser) parseRvalue() interface{} {
 	tok := p.getToken()
 	if tok == nil || tok.typ == tokenEOF {
 		p.raiseError(tok, "expecting a value")
 	}
 
-	switch tok.typ {
+	// The lexer only emits the single tokenDate kind for any date/time-shaped
+	// literal; narrow it to the precise TOML 1.0 kind its value represents
+	// so the tokenLocalDate/tokenLocalTime/tokenLocalDateTime cases below
+	// are reachable at all.
+	typ := tok.typ
+	if typ == tokenDate {
+		typ = classifyDateToken(tok.val)
+	}
+
+	// Scalar values get their span and raw text recorded for round-trip
+	// editing; arrays and inline tables are composite and parsed by
+	// dedicated sub-parsers below, so they're left for a future pass.
+	if sr, ok := p.builder.(spanRecorder); ok {
+		switch typ {
+		case tokenString, tokenTrue, tokenFalse, tokenInf, tokenNan, tokenInteger, tokenFloat, tokenDate,
+			tokenLocalDate, tokenLocalTime, tokenLocalDateTime:
+			defer func() {
+				// tok.val for tokenString is the lexer's decoded value (quotes
+				// stripped, escapes resolved), not the source text, so it has
+				// to be re-quoted before it can stand in as raw TOML again.
+				raw := tok.val
+				if typ == tokenString {
+					raw = formatValue(tok.val)
+				}
+				sr.recordSpan(Span{Start: tok.Position, End: spanEnd(tok)}, raw)
+			}()
+		}
+	}
+
+	switch typ {
 	case tokenString:
 		p.builder.foundValue(tok.val, &tok.Position)
 		return tok.val
@@ -237,6 +414,27 @@ func (p *tomlParser) parseRvalue() interface{} {
 		}
 		p.builder.foundValue(val, &tok.Position)
 		return val
+	case tokenLocalDate:
+		val, err := parseLocalDate(tok.val)
+		if err != nil {
+			p.raiseError(tok, "%s", err)
+		}
+		p.builder.foundValue(val, &tok.Position)
+		return val
+	case tokenLocalTime:
+		val, err := parseLocalTime(tok.val)
+		if err != nil {
+			p.raiseError(tok, "%s", err)
+		}
+		p.builder.foundValue(val, &tok.Position)
+		return val
+	case tokenLocalDateTime:
+		val, err := parseLocalDateTime(tok.val)
+		if err != nil {
+			p.raiseError(tok, "%s", err)
+		}
+		p.builder.foundValue(val, &tok.Position)
+		return val
 	case tokenLeftBracket:
 		p.parseArray()
 		return nil
@@ -326,15 +524,26 @@ func (p *tomlParser) parseArray() {
 	p.builder.exitArray()
 }
 
-func parseToml(flow []token) *Tree {
+// parseToml builds a *Tree out of flow. By default a malformed document
+// aborts parsing with a panic carrying a *ParseError; pass ContinueOnError
+// to instead collect every ParseError found in the document and return
+// them alongside the partial tree. The returned *FormatInfo carries the
+// source spans, raw text and comments needed to round-trip the document
+// with WriteTo/Marshal.
+func parseToml(flow []token, opts ...ParserOption) (*Tree, *FormatInfo, []ParseError) {
 	builder := makeTreeBuilder()
 	parser := &tomlParser{
 		flowIdx: 0,
 		flow:    flow,
 		builder: builder,
 	}
+	for _, opt := range opts {
+		opt(&parser.opts)
+	}
+	builder.continueOnError = parser.opts.continueOnError
+	builder.spec = parser.opts.spec
 	parser.run()
-	return builder.tree
+	return builder.tree, builder.format, parser.errors
 }
 
 func init() {
@@ -0,0 +1,38 @@
+package toml
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseLocalDateTimeValues guards against tokenLocalDate/tokenLocalTime/
+// tokenLocalDateTime going unreached: the lexer only ever produces the
+// single tokenDate kind for a date/time-shaped literal, so parseRvalue has
+// to tell local values apart from offset date-times itself.
+func TestParseLocalDateTimeValues(t *testing.T) {
+	tree, _, errs := parseToml(lexToml([]byte(`
+d  = 1979-05-27
+lt = 07:32:00
+dt = 1979-05-27T07:32:00
+od = 1979-05-27T07:32:00Z
+`)))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if got, want := tree.Get("d"), (LocalDate{Year: 1979, Month: 5, Day: 27}); got != want {
+		t.Errorf("d = %#v, want %#v", got, want)
+	}
+	if got, want := tree.Get("lt"), (LocalTime{Hour: 7, Minute: 32, Second: 0}); got != want {
+		t.Errorf("lt = %#v, want %#v", got, want)
+	}
+	if got, want := tree.Get("dt"), (LocalDateTime{
+		Date: LocalDate{Year: 1979, Month: 5, Day: 27},
+		Time: LocalTime{Hour: 7, Minute: 32, Second: 0},
+	}); got != want {
+		t.Errorf("dt = %#v, want %#v", got, want)
+	}
+	if _, ok := tree.Get("od").(time.Time); !ok {
+		t.Errorf("od = %#v (%T), want a time.Time (offset date-time must still parse as before)", tree.Get("od"), tree.Get("od"))
+	}
+}
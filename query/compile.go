@@ -0,0 +1,168 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// compiler is a small recursive-descent parser turning a path expression
+// into a slice of segment matchers, one per path step.
+type compiler struct {
+	input string
+	pos   int
+}
+
+func (c *compiler) compile() ([]segment, error) {
+	if !strings.HasPrefix(c.input, "$") {
+		return nil, fmt.Errorf("query: path must start with $, got %q", c.input)
+	}
+	c.pos = 1
+
+	var segments []segment
+	for c.pos < len(c.input) {
+		seg, err := c.compileSegment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg...)
+	}
+	return segments, nil
+}
+
+func (c *compiler) compileSegment() ([]segment, error) {
+	rest := c.input[c.pos:]
+	switch {
+	case strings.HasPrefix(rest, ".."):
+		c.pos += 2
+		key, err := c.compileKeyName()
+		if err != nil {
+			return nil, err
+		}
+		segs := []segment{descendantSegment{}}
+		if key != "" {
+			segs = append(segs, childSegment{key: key})
+		}
+		return segs, nil
+	case strings.HasPrefix(rest, "."):
+		c.pos++
+		key, err := c.compileKeyName()
+		if err != nil {
+			return nil, err
+		}
+		return []segment{childSegment{key: key}}, nil
+	case strings.HasPrefix(rest, "["):
+		seg, err := c.compileBracket()
+		if err != nil {
+			return nil, err
+		}
+		return []segment{seg}, nil
+	default:
+		return nil, fmt.Errorf("query: unexpected character %q at position %d", rest[0], c.pos)
+	}
+}
+
+// compileKeyName reads an identifier or "*" up to the next '.' or '['.
+func (c *compiler) compileKeyName() (string, error) {
+	start := c.pos
+	for c.pos < len(c.input) {
+		ch := c.input[c.pos]
+		if ch == '.' || ch == '[' {
+			break
+		}
+		c.pos++
+	}
+	if c.pos == start {
+		return "", fmt.Errorf("query: expected a key at position %d", start)
+	}
+	return c.input[start:c.pos], nil
+}
+
+// compileBracket parses the contents of a "[...]" segment: an index, a
+// slice ("start:end"), or a filter ("?(@.key op value)").
+func (c *compiler) compileBracket() (segment, error) {
+	if c.input[c.pos] != '[' {
+		return nil, fmt.Errorf("query: expected '[' at position %d", c.pos)
+	}
+	end := strings.IndexByte(c.input[c.pos:], ']')
+	if end < 0 {
+		return nil, fmt.Errorf("query: unterminated '[' at position %d", c.pos)
+	}
+	body := c.input[c.pos+1 : c.pos+end]
+	c.pos += end + 1
+
+	switch {
+	case strings.HasPrefix(body, "?("):
+		return c.compileFilter(body)
+	case strings.Contains(body, ":"):
+		return c.compileSlice(body)
+	default:
+		idx, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid index %q", body)
+		}
+		return indexSegment{index: idx}, nil
+	}
+}
+
+func (c *compiler) compileSlice(body string) (segment, error) {
+	parts := strings.SplitN(body, ":", 2)
+	start, end := 0, -1
+	var err error
+	if parts[0] != "" {
+		start, err = strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid slice start %q", parts[0])
+		}
+	}
+	if parts[1] != "" {
+		end, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("query: invalid slice end %q", parts[1])
+		}
+	}
+	return sliceSegment{start: start, end: end}, nil
+}
+
+// compileFilter parses "?(@.key)", "?(@.key == value)" and
+// "?(@.key != value)".
+func (c *compiler) compileFilter(body string) (segment, error) {
+	expr := strings.TrimSuffix(strings.TrimPrefix(body, "?("), ")")
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "@.") {
+		return nil, fmt.Errorf("query: filter expression must start with @., got %q", expr)
+	}
+	expr = strings.TrimPrefix(expr, "@.")
+
+	for _, op := range []string{"==", "!="} {
+		if idx := strings.Index(expr, op); idx >= 0 {
+			key := strings.TrimSpace(expr[:idx])
+			value := strings.TrimSpace(expr[idx+len(op):])
+			return filterSegment{predicate: filterPredicate{
+				key:   key,
+				op:    op,
+				value: parseFilterLiteral(value),
+			}}, nil
+		}
+	}
+
+	return filterSegment{predicate: filterPredicate{key: strings.TrimSpace(expr)}}, nil
+}
+
+// parseFilterLiteral parses the right-hand side of a filter comparison: a
+// quoted string, a boolean, or a number, falling back to the raw text.
+func parseFilterLiteral(s string) interface{} {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
@@ -0,0 +1,288 @@
+// Package query implements a small JSONPath/TOMLPath-style query language
+// over *toml.Tree, so callers can extract a subset of a large config
+// without hand-walking it with GetPath. Supported syntax:
+//
+//	$.servers.alpha.ip     child
+//	$.servers.*.ip         wildcard child
+//	$..ip                  descendant (any depth)
+//	$.servers[0]           array index
+//	$.servers[0:2]         array slice
+//	$.servers[?(@.enabled)]  filter
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	toml "github.com/gxed/go-toml"
+)
+
+// Result is one value matched by a Query, together with the dotted path it
+// was found at and its source Position, if the tree has one recorded for
+// that key.
+type Result struct {
+	Path     string
+	Value    interface{}
+	Position toml.Position
+}
+
+// match is a (value, path, position) triple alive at the query execution
+// frontier.
+type match struct {
+	value interface{}
+	path  []string
+	pos   toml.Position
+}
+
+// segment matches one step of a compiled path against a single node,
+// producing the nodes it leads to.
+type segment interface {
+	Apply(m match) []match
+}
+
+// Query is a compiled path expression that can be run against any number
+// of *toml.Tree values.
+type Query struct {
+	segments []segment
+}
+
+// Compile parses path into a Query.
+func Compile(path string) (*Query, error) {
+	c := &compiler{input: path}
+	segs, err := c.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{segments: segs}, nil
+}
+
+// Execute runs the query against tree, returning every matching value. It
+// performs a breadth-first walk: each segment is applied to every node at
+// the current frontier before the next segment runs, so a `..` segment
+// only expands the part of the tree still in play rather than the whole
+// document up front.
+func (q *Query) Execute(tree *toml.Tree) []Result {
+	frontier := []match{{value: tree}}
+
+	for _, seg := range q.segments {
+		var next []match
+		for _, m := range frontier {
+			next = append(next, seg.Apply(m)...)
+		}
+		frontier = next
+	}
+
+	results := make([]Result, 0, len(frontier))
+	for _, m := range frontier {
+		results = append(results, Result{
+			Path:     strings.Join(m.path, "."),
+			Value:    m.value,
+			Position: m.pos,
+		})
+	}
+	return results
+}
+
+func extend(path []string, key string) []string {
+	out := make([]string, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, key)
+}
+
+// children returns the direct key/value pairs of value if it is a
+// *toml.Tree, and nil otherwise.
+func children(value interface{}) (keys []string, get func(string) interface{}, pos func(string) toml.Position) {
+	tree, ok := value.(*toml.Tree)
+	if !ok {
+		return nil, nil, nil
+	}
+	return tree.Keys(), tree.Get, tree.GetPosition
+}
+
+// itemPosition returns the source Position recorded for value if it is a
+// *toml.Tree, and the zero Position otherwise (e.g. a scalar array
+// element, which carries no Position of its own).
+func itemPosition(value interface{}) toml.Position {
+	if tree, ok := value.(*toml.Tree); ok {
+		return tree.Position()
+	}
+	return toml.Position{}
+}
+
+// childSegment matches a named child key, or every child when key is "*".
+type childSegment struct {
+	key string
+}
+
+func (s childSegment) Apply(m match) []match {
+	keys, get, pos := children(m.value)
+	if get == nil {
+		return nil
+	}
+
+	if s.key == "*" {
+		out := make([]match, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, match{value: get(k), path: extend(m.path, k), pos: pos(k)})
+		}
+		return out
+	}
+
+	for _, k := range keys {
+		if k == s.key {
+			return []match{{value: get(k), path: extend(m.path, k), pos: pos(k)}}
+		}
+	}
+	return nil
+}
+
+// descendantSegment matches the current node and every node beneath it, at
+// any depth.
+type descendantSegment struct{}
+
+func (s descendantSegment) Apply(m match) []match {
+	var out []match
+	var walk func(m match)
+	walk = func(m match) {
+		out = append(out, m)
+		keys, get, pos := children(m.value)
+		for _, k := range keys {
+			walk(match{value: get(k), path: extend(m.path, k), pos: pos(k)})
+		}
+		switch v := m.value.(type) {
+		case []*toml.Tree:
+			for i, item := range v {
+				walk(match{value: item, path: extend(m.path, strconv.Itoa(i)), pos: itemPosition(item)})
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(match{value: item, path: extend(m.path, strconv.Itoa(i)), pos: itemPosition(item)})
+			}
+		}
+	}
+	walk(m)
+	return out
+}
+
+// indexSegment matches a single element of an array by position.
+type indexSegment struct {
+	index int
+}
+
+func (s indexSegment) Apply(m match) []match {
+	switch v := m.value.(type) {
+	case []*toml.Tree:
+		if s.index < 0 || s.index >= len(v) {
+			return nil
+		}
+		item := v[s.index]
+		return []match{{value: item, path: extend(m.path, strconv.Itoa(s.index)), pos: itemPosition(item)}}
+	case []interface{}:
+		if s.index < 0 || s.index >= len(v) {
+			return nil
+		}
+		item := v[s.index]
+		return []match{{value: item, path: extend(m.path, strconv.Itoa(s.index)), pos: itemPosition(item)}}
+	}
+	return nil
+}
+
+// sliceSegment matches a contiguous range of an array, [start, end).
+type sliceSegment struct {
+	start, end int
+}
+
+func (s sliceSegment) Apply(m match) []match {
+	length := 0
+	switch v := m.value.(type) {
+	case []*toml.Tree:
+		length = len(v)
+	case []interface{}:
+		length = len(v)
+	default:
+		return nil
+	}
+
+	start, end := s.start, s.end
+	if end < 0 || end > length {
+		end = length
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	var out []match
+	for i := start; i < end; i++ {
+		out = append(out, indexSegment{index: i}.Apply(m)...)
+	}
+	return out
+}
+
+// filterSegment matches the elements of an array, or the single current
+// node, whose predicate evaluates true.
+type filterSegment struct {
+	predicate filterPredicate
+}
+
+func (s filterSegment) Apply(m match) []match {
+	var candidates []match
+	switch v := m.value.(type) {
+	case []*toml.Tree:
+		for i, item := range v {
+			candidates = append(candidates, match{value: item, path: extend(m.path, strconv.Itoa(i)), pos: itemPosition(item)})
+		}
+	case []interface{}:
+		for i, item := range v {
+			candidates = append(candidates, match{value: item, path: extend(m.path, strconv.Itoa(i)), pos: itemPosition(item)})
+		}
+	default:
+		candidates = []match{m}
+	}
+
+	var out []match
+	for _, c := range candidates {
+		if s.predicate.matches(c.value) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// filterPredicate evaluates "@.key", "@.key == literal" and
+// "@.key != literal" filter expressions against a node.
+type filterPredicate struct {
+	key   string
+	op    string // "", "==" or "!="
+	value interface{}
+}
+
+func (p filterPredicate) matches(node interface{}) bool {
+	_, get, _ := children(node)
+	if get == nil {
+		return false
+	}
+	v := get(p.key)
+
+	switch p.op {
+	case "":
+		return truthy(v)
+	case "==":
+		return fmt.Sprintf("%v", v) == fmt.Sprintf("%v", p.value)
+	case "!=":
+		return fmt.Sprintf("%v", v) != fmt.Sprintf("%v", p.value)
+	default:
+		return false
+	}
+}
+
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	default:
+		return true
+	}
+}
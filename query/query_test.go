@@ -0,0 +1,169 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	toml "github.com/gxed/go-toml"
+)
+
+// TestExecuteArrayPositions guards against indexSegment leaving
+// Result.Position at its zero value for array elements that do have a
+// recorded Position, e.g. the tables of a table array.
+func TestExecuteArrayPositions(t *testing.T) {
+	src := `
+[[servers]]
+ip = "10.0.0.1"
+
+[[servers]]
+ip = "10.0.0.2"
+`
+	tree, err := toml.Load(src)
+	if err != nil {
+		t.Fatalf("toml.Load: %v", err)
+	}
+
+	q, err := Compile("$.servers[0]")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	results := q.Execute(tree)
+	if len(results) != 1 {
+		t.Fatalf("Execute returned %d results, want 1", len(results))
+	}
+
+	if results[0].Position == (toml.Position{}) {
+		t.Error("Position is zero for a table array element, want its recorded source Position")
+	}
+}
+
+// TestExecuteWildcard guards against childSegment's "*" case only matching
+// the first child key instead of every child.
+func TestExecuteWildcard(t *testing.T) {
+	src := `
+[servers.alpha]
+ip = "10.0.0.1"
+
+[servers.beta]
+ip = "10.0.0.2"
+`
+	tree, err := toml.Load(src)
+	if err != nil {
+		t.Fatalf("toml.Load: %v", err)
+	}
+
+	q, err := Compile("$.servers.*.ip")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	results := q.Execute(tree)
+	if len(results) != 2 {
+		t.Fatalf("Execute returned %d results, want 2", len(results))
+	}
+
+	got := map[string]bool{}
+	for _, r := range results {
+		got[fmt.Sprintf("%v", r.Value)] = true
+	}
+	if !got["10.0.0.1"] || !got["10.0.0.2"] {
+		t.Errorf("results = %v, want both servers' ip values", results)
+	}
+}
+
+// TestExecuteDescendant guards against descendantSegment only walking the
+// current node's direct children instead of the whole subtree beneath it.
+func TestExecuteDescendant(t *testing.T) {
+	src := `
+[server]
+ip = "10.0.0.1"
+
+[server.admin]
+ip = "10.0.0.2"
+`
+	tree, err := toml.Load(src)
+	if err != nil {
+		t.Fatalf("toml.Load: %v", err)
+	}
+
+	q, err := Compile("$..ip")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	results := q.Execute(tree)
+	if len(results) != 2 {
+		t.Fatalf("Execute returned %d results, want 2 (one at any depth): %v", len(results), results)
+	}
+}
+
+// TestExecuteSlice guards against sliceSegment mishandling its [start,end)
+// bounds, e.g. off-by-one errors or an open-ended end not reaching the
+// last element.
+func TestExecuteSlice(t *testing.T) {
+	src := `
+[[servers]]
+ip = "10.0.0.1"
+
+[[servers]]
+ip = "10.0.0.2"
+
+[[servers]]
+ip = "10.0.0.3"
+`
+	tree, err := toml.Load(src)
+	if err != nil {
+		t.Fatalf("toml.Load: %v", err)
+	}
+
+	q, err := Compile("$.servers[1:]")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	results := q.Execute(tree)
+	if len(results) != 2 {
+		t.Fatalf("Execute returned %d results, want 2 (indices 1 and 2, end unspecified): %v", len(results), results)
+	}
+	if results[0].Path != "servers.1" || results[1].Path != "servers.2" {
+		t.Errorf("Paths = [%q, %q], want [%q, %q]", results[0].Path, results[1].Path, "servers.1", "servers.2")
+	}
+}
+
+// TestExecuteFilter guards against filterSegment/filterPredicate
+// mis-evaluating a bare existence-and-truthiness check and an "=="
+// comparison against array elements.
+func TestExecuteFilter(t *testing.T) {
+	src := `
+[[servers]]
+ip = "10.0.0.1"
+enabled = true
+
+[[servers]]
+ip = "10.0.0.2"
+enabled = false
+`
+	tree, err := toml.Load(src)
+	if err != nil {
+		t.Fatalf("toml.Load: %v", err)
+	}
+
+	q, err := Compile("$.servers[?(@.enabled)]")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	results := q.Execute(tree)
+	if len(results) != 1 {
+		t.Fatalf("Execute returned %d results, want 1 (only the enabled server)", len(results))
+	}
+
+	qEq, err := Compile(`$.servers[?(@.ip == "10.0.0.2")]`)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	eqResults := qEq.Execute(tree)
+	if len(eqResults) != 1 {
+		t.Fatalf("Execute returned %d results, want 1 (only the matching ip)", len(eqResults))
+	}
+}
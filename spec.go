@@ -0,0 +1,22 @@
+package toml
+
+// Spec selects which version of the TOML specification the parser
+// enforces.
+type Spec int
+
+const (
+	// Spec10 parses TOML 1.0: dotted keys in assignments, mixed-type
+	// arrays, and local date/time/datetime values. This is the default.
+	Spec10 Spec = iota
+	// Spec04 restores the stricter TOML 0.4 behavior: a dotted key in an
+	// assignment is a parse error and arrays must be homogeneous.
+	Spec04
+)
+
+// WithSpec pins the parser to a specific TOML specification version,
+// overriding the default of Spec10.
+func WithSpec(s Spec) ParserOption {
+	return func(o *parserOptions) {
+		o.spec = s
+	}
+}